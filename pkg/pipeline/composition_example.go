@@ -0,0 +1,312 @@
+/*
+Copyright 2022 Upbound Inc.
+*/
+
+package pipeline
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/upjet/pkg/config"
+)
+
+// CompositionExampleGenerator turns a multi-resource HCL example scope (see
+// parseHCLExample) into a runnable Composition and CompositeResourceDefinition
+// pair under examples-generated/compositions/<group>. Unlike the single-MR
+// examples ExampleGenerator produces, it reuses the same resolved topology
+// the manifest generator sees instead of flattening cross-resource
+// references into literal string substitutions: every ${a.b.field}
+// reference between two resources of the scope becomes a patches: entry
+// instead.
+type CompositionExampleGenerator struct {
+	rootDir        string
+	configResource map[string]*config.Resource
+}
+
+// NewCompositionExampleGenerator returns a configured CompositionExampleGenerator.
+func NewCompositionExampleGenerator(rootDir string, configResource map[string]*config.Resource) *CompositionExampleGenerator {
+	return &CompositionExampleGenerator{
+		rootDir:        rootDir,
+		configResource: configResource,
+	}
+}
+
+// compositionRef is one ${a.b.field}-style edge found between two resources
+// declared in the same example scope.
+type compositionRef struct {
+	fromName, fromFieldPath string
+	toName, toFieldPath     string
+}
+
+// Generate emits a Composition and its CompositeResourceDefinition for the
+// given multi-resource HCL example scope. name becomes the output files'
+// basename, and the XRD's kind is "X" followed by name in PascalCase. Scopes with a single resource are skipped:
+// a composition only makes sense across more than one.
+func (cg *CompositionExampleGenerator) Generate(group, name string, scope *hclExampleScope) error {
+	if len(scope.resources) < 2 {
+		return nil
+	}
+
+	refs := findCompositionRefs(scope)
+	hclNames := make([]string, 0, len(scope.resources))
+	for n := range scope.resources {
+		hclNames = append(hclNames, n)
+	}
+	sort.Strings(hclNames)
+
+	composed := make([]interface{}, 0, len(hclNames))
+	for _, hclName := range hclNames {
+		rc, ok := cg.configResource[resourceType(hclName)]
+		if !ok {
+			// we only have TF metadata for resource types this provider
+			// configures; skip anything else declared in the same snippet.
+			continue
+		}
+		manifest := copyManifest(scope.resources[hclName])
+
+		var patches []interface{}
+		for _, r := range refs {
+			switch hclName {
+			case r.toName:
+				patches = append(patches, map[string]interface{}{
+					"type":          "ToCompositeFieldPath",
+					"fromFieldPath": fmt.Sprintf("status.atProvider.%s", r.toFieldPath),
+					"toFieldPath":   fmt.Sprintf("status.%s", refStatusKey(r)),
+				})
+			case r.fromName:
+				// the static "${...}" placeholder this field held is
+				// superseded by the patch wiring it to the referenced
+				// resource's own value at composition time.
+				deleteFieldPath(manifest, r.fromFieldPath)
+				patches = append(patches, map[string]interface{}{
+					"type":          "FromCompositeFieldPath",
+					"fromFieldPath": fmt.Sprintf("status.%s", refStatusKey(r)),
+					"toFieldPath":   fmt.Sprintf("spec.forProvider.%s", r.fromFieldPath),
+				})
+			}
+		}
+
+		composed = append(composed, map[string]interface{}{
+			"name": hclName,
+			"base": map[string]interface{}{
+				"apiVersion": fmt.Sprintf("%s/%s", group, rc.Version),
+				"kind":       rc.Kind,
+				"spec": map[string]interface{}{
+					"forProvider": manifest,
+				},
+			},
+			"patches": patches,
+		})
+	}
+
+	xrdKind := fmt.Sprintf("X%s", pascalCase(name))
+	plural := fmt.Sprintf("%ss", strings.ToLower(name))
+
+	composition := map[string]interface{}{
+		"apiVersion": "apiextensions.crossplane.io/v1",
+		"kind":       "Composition",
+		"metadata": map[string]interface{}{
+			"name": fmt.Sprintf("%s.%s", name, group),
+		},
+		"spec": map[string]interface{}{
+			"compositeTypeRef": map[string]interface{}{
+				"apiVersion": fmt.Sprintf("%s/v1alpha1", group),
+				"kind":       xrdKind,
+			},
+			"resources": composed,
+		},
+	}
+	xrd := map[string]interface{}{
+		"apiVersion": "apiextensions.crossplane.io/v1",
+		"kind":       "CompositeResourceDefinition",
+		"metadata": map[string]interface{}{
+			"name": fmt.Sprintf("%s.%s", plural, group),
+		},
+		"spec": map[string]interface{}{
+			"group": group,
+			"names": map[string]interface{}{
+				"kind":   xrdKind,
+				"plural": plural,
+			},
+			"versions": []interface{}{
+				map[string]interface{}{
+					"name":          "v1alpha1",
+					"served":        true,
+					"referenceable": true,
+					"schema": map[string]interface{}{
+						"openAPIV3Schema": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"spec": map[string]interface{}{
+									"type": "object",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	dir := filepath.Join(cg.rootDir, "examples-generated", "compositions", strings.ToLower(strings.Split(group, ".")[0]))
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return errors.Wrapf(err, "cannot mkdir %s", dir)
+	}
+	if err := writeExampleYAML(filepath.Join(dir, fmt.Sprintf("%s.composition.yaml", name)), composition); err != nil {
+		return errors.Wrapf(err, "cannot write composition example for %s", name)
+	}
+	if err := writeExampleYAML(filepath.Join(dir, fmt.Sprintf("%s.xrd.yaml", name)), xrd); err != nil {
+		return errors.Wrapf(err, "cannot write composite resource definition example for %s", name)
+	}
+	return nil
+}
+
+// findCompositionRefs walks every resource manifest in scope and collects
+// the ${a.b.field}-style edges between resources that are both part of it.
+// The result is sorted by (fromName, fromFieldPath, toName, toFieldPath) so
+// that Generate's emitted patches: order is byte-stable across runs,
+// regardless of Go's randomized map iteration order.
+func findCompositionRefs(scope *hclExampleScope) []compositionRef {
+	var refs []compositionRef
+	for name, manifest := range scope.resources {
+		collectRefs(manifest, "", name, scope, &refs)
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		a, b := refs[i], refs[j]
+		if a.fromName != b.fromName {
+			return a.fromName < b.fromName
+		}
+		if a.fromFieldPath != b.fromFieldPath {
+			return a.fromFieldPath < b.fromFieldPath
+		}
+		if a.toName != b.toName {
+			return a.toName < b.toName
+		}
+		return a.toFieldPath < b.toFieldPath
+	})
+	return refs
+}
+
+func collectRefs(v interface{}, fieldPath, fromName string, scope *hclExampleScope, refs *[]compositionRef) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, vv := range t {
+			collectRefs(vv, getHierarchicalName(fieldPath, k), fromName, scope, refs)
+		}
+
+	case []interface{}:
+		for _, e := range t {
+			collectRefs(e, fieldPath, fromName, scope, refs)
+		}
+
+	case string:
+		g := reRef.FindStringSubmatch(t)
+		if len(g) != 2 {
+			return
+		}
+		path := strings.Split(g[1], ".")
+		if len(path) < 3 {
+			return
+		}
+		toName := fmt.Sprintf("%s.%s", path[0], path[1])
+		if _, ok := scope.resources[toName]; !ok {
+			// references a resource outside this scope; nothing to patch
+			return
+		}
+		*refs = append(*refs, compositionRef{
+			fromName:      fromName,
+			fromFieldPath: fieldPath,
+			toName:        toName,
+			toFieldPath:   strings.Join(path[2:], "."),
+		})
+	}
+}
+
+// refStatusKey derives a stable composite-status field name to funnel a
+// reference's value through, e.g. "aws_vpc.main" + "id" -> "awsVpcMainId".
+func refStatusKey(r compositionRef) string {
+	raw := fmt.Sprintf("%s_%s", strings.ReplaceAll(r.toName, ".", "_"), strings.ReplaceAll(r.toFieldPath, ".", "_"))
+	parts := strings.Split(raw, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] == "" {
+			continue
+		}
+		parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+	}
+	return strings.Join(parts, "")
+}
+
+// resourceType returns the leading "<type>" of a "<type>.<name>" HCL
+// resource key, e.g. "aws_vpc" for "aws_vpc.main".
+func resourceType(hclName string) string {
+	return strings.SplitN(hclName, ".", 2)[0]
+}
+
+// pascalCase turns a hyphen/underscore-separated name, e.g.
+// "aws_subnet-example-1", into clean PascalCase, e.g. "AwsSubnetExample1",
+// matching the Kind naming convention every other generator in this
+// codebase produces.
+func pascalCase(name string) string {
+	fields := strings.FieldsFunc(name, func(r rune) bool {
+		return r == '-' || r == '_'
+	})
+	for i, f := range fields {
+		fields[i] = strings.Title(f) //nolint:staticcheck // simple ASCII field names, no unicode caveats
+	}
+	return strings.Join(fields, "")
+}
+
+func copyManifest(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = copyValue(v)
+	}
+	return out
+}
+
+func copyValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		return copyManifest(t)
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, e := range t {
+			out[i] = copyValue(e)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// deleteFieldPath removes the dotted attribute path (as produced by
+// getHierarchicalName) from manifest.
+func deleteFieldPath(manifest map[string]interface{}, path string) {
+	parts := strings.Split(path, ".")
+	cur := manifest
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := cur[p].(map[string]interface{})
+		if !ok {
+			return
+		}
+		cur = next
+	}
+	delete(cur, parts[len(parts)-1])
+}
+
+func writeExampleYAML(path string, v interface{}) error {
+	buff, err := yaml.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal to YAML")
+	}
+	// no sensitive info in the composition/XRD example
+	return ioutil.WriteFile(path, buff, 0644) // nolint:gosec
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2022 Upbound Inc.
+*/
+
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindCompositionRefsIsSortedAndStable(t *testing.T) {
+	scope := &hclExampleScope{
+		resources: map[string]map[string]interface{}{
+			"aws_vpc.main": {
+				"cidr_block": "10.0.0.0/16",
+			},
+			"aws_subnet.private": {
+				"vpc_id":     "${aws_vpc.main.id}",
+				"cidr_block": "10.0.2.0/24",
+			},
+			"aws_subnet.public": {
+				"vpc_id":     "${aws_vpc.main.id}",
+				"cidr_block": "10.0.1.0/24",
+				"nested": map[string]interface{}{
+					"owner": "${aws_vpc.main.owner_id}",
+				},
+			},
+		},
+	}
+
+	var want []compositionRef
+	for i := 0; i < 10; i++ {
+		refs := findCompositionRefs(scope)
+		if i == 0 {
+			want = refs
+			continue
+		}
+		if !reflect.DeepEqual(refs, want) {
+			t.Fatalf("findCompositionRefs is not deterministic across calls:\nfirst: %#v\nlater: %#v", want, refs)
+		}
+	}
+
+	for i := 1; i < len(want); i++ {
+		a, b := want[i-1], want[i]
+		if a.fromName > b.fromName || (a.fromName == b.fromName && a.fromFieldPath > b.fromFieldPath) {
+			t.Fatalf("refs not sorted by (fromName, fromFieldPath) at index %d: %#v then %#v", i, a, b)
+		}
+	}
+
+	for _, r := range want {
+		if r.toName != "aws_vpc.main" {
+			t.Errorf("unexpected toName %q, every ref in this scope points at aws_vpc.main", r.toName)
+		}
+	}
+}
+
+func TestPascalCase(t *testing.T) {
+	cases := map[string]string{
+		"aws_subnet-example-1": "AwsSubnetExample1",
+		"aws_vpc":              "AwsVpc",
+		"example":              "Example",
+	}
+	for in, want := range cases {
+		if got := pascalCase(in); got != want {
+			t.Errorf("pascalCase(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestCollectRefsIgnoresReferencesOutsideScope(t *testing.T) {
+	scope := &hclExampleScope{
+		resources: map[string]map[string]interface{}{
+			"aws_subnet.primary": {
+				"vpc_id": "${aws_vpc.unrelated.id}",
+			},
+		},
+	}
+	var refs []compositionRef
+	collectRefs(scope.resources["aws_subnet.primary"], "", "aws_subnet.primary", scope, &refs)
+	if len(refs) != 0 {
+		t.Errorf("expected no refs for a target outside the scope, got %#v", refs)
+	}
+}
@@ -15,10 +15,12 @@ import (
 	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
 	xpmeta "github.com/crossplane/crossplane-runtime/pkg/meta"
 	"github.com/pkg/errors"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"sigs.k8s.io/yaml"
 
-	"github.com/upbound/upjet/pkg/config"
-	tjtypes "github.com/upbound/upjet/pkg/types"
+	"github.com/crossplane/upjet/pkg/config"
+	"github.com/crossplane/upjet/pkg/pipeline/examplevalidate"
+	tjtypes "github.com/crossplane/upjet/pkg/types"
 )
 
 var (
@@ -30,6 +32,22 @@ type pavedWithManifest struct {
 	manifestPath string
 	paved        *fieldpath.Paved
 	refsResolved bool
+	// exampleID scopes this instance to the one documented example it was
+	// parsed from (see exampleID), so resolveReferences only ever matches it
+	// against sibling resources declared in that same HCL snippet.
+	exampleID            string
+	group, version, kind string
+}
+
+// CRDGetter looks up the generated CRD for a group/version/kind, e.g.
+// CRDGenerator's own schema cache for the current pipeline run.
+type CRDGetter func(group, version, kind string) (*apiextensionsv1.CustomResourceDefinition, error)
+
+// exampleScope holds every documented example generated for a single
+// Terraform resource - the ones actually written out to
+// examples-generated by StoreExamples.
+type exampleScope struct {
+	examples []*pavedWithManifest
 }
 
 // ExampleGenerator represents a pipeline for generating example manifests.
@@ -37,7 +55,20 @@ type pavedWithManifest struct {
 type ExampleGenerator struct {
 	rootDir        string
 	configResource map[string]*config.Resource
-	resources      map[string]*pavedWithManifest
+	// primary holds, per Terraform resource name, the example(s) that are
+	// actually written out by StoreExamples.
+	primary map[string]*exampleScope
+	// resources indexes every resource instance known from any parsed
+	// example, scoped per example first (see exampleID) and then by the
+	// instance's full "<resource type>.<resource name>", e.g. "aws_vpc.main",
+	// within that example. This is what resolveReferences looks a reference
+	// up in, so "${aws_vpc.main.id}" always resolves against the aws_vpc.main
+	// block declared in the *same* documented example, never a same-named
+	// sibling from a different example of the same (or another) resource.
+	resources      map[string]map[string]*pavedWithManifest
+	compositionGen *CompositionExampleGenerator
+	crdFor         CRDGetter
+	validateConfig examplevalidate.Config
 }
 
 // NewExampleGenerator returns a configured ExampleGenerator
@@ -45,48 +76,109 @@ func NewExampleGenerator(rootDir string, configResource map[string]*config.Resou
 	return &ExampleGenerator{
 		rootDir:        rootDir,
 		configResource: configResource,
-		resources:      make(map[string]*pavedWithManifest),
+		primary:        make(map[string]*exampleScope),
+		resources:      make(map[string]map[string]*pavedWithManifest),
 	}
 }
 
+// WithCRDValidation makes StoreExamples validate every generated example
+// against the CRD schema of its own group/version/kind before writing it
+// out, using crdFor to look up that CRD. This closes the loop between the
+// CRD and example generators, which would otherwise be free to drift apart
+// silently.
+func (eg *ExampleGenerator) WithCRDValidation(crdFor CRDGetter, cfg examplevalidate.Config) {
+	eg.crdFor = crdFor
+	eg.validateConfig = cfg
+}
+
+// WithCompositions makes Generate also emit a Composition/XRD pair via cg
+// whenever a documented example's HCL snippet declares more than one
+// resource, reusing the exact same parsed scope the manifest generator
+// builds its pavedWithManifest entries from.
+func (eg *ExampleGenerator) WithCompositions(cg *CompositionExampleGenerator) {
+	eg.compositionGen = cg
+}
+
 // StoreExamples stores the generated example manifests under examples-generated in
 // their respective API groups.
 func (eg *ExampleGenerator) StoreExamples() error {
-	for n, pm := range eg.resources {
-		if err := eg.resolveReferencesOfPaved(pm); err != nil {
-			return errors.Wrapf(err, "cannot resolve references for resource: %s", n)
-		}
-		u := pm.paved.UnstructuredContent()
-		delete(u["spec"].(map[string]interface{})["forProvider"].(map[string]interface{}), "depends_on")
-		buff, err := yaml.Marshal(u)
-		if err != nil {
-			return errors.Wrapf(err, "cannot marshal example manifest for resource: %s", n)
-		}
-		manifestDir := filepath.Dir(pm.manifestPath)
-		if err := os.MkdirAll(manifestDir, 0750); err != nil {
-			return errors.Wrapf(err, "cannot mkdir %s", manifestDir)
-		}
-		// no sensitive info in the example manifest
-		if err := ioutil.WriteFile(pm.manifestPath, buff, 0644); err != nil { // nolint:gosec
-			return errors.Wrapf(err, "cannot write example manifest file %s for resource %s", pm.manifestPath, n)
+	seenManifestPaths := make(map[string]struct{})
+	for n, scope := range eg.primary {
+		for _, pm := range scope.examples {
+			if pm == nil {
+				continue
+			}
+			if err := eg.resolveReferencesOfPaved(pm); err != nil {
+				return errors.Wrapf(err, "cannot resolve references for resource: %s", n)
+			}
+			// examples of different resources can end up targeting the same
+			// manifest path, which is built from the group's first segment
+			// and the lowercased kind only, e.g. the same kind generated for
+			// two API versions of a group; only write it once.
+			if _, ok := seenManifestPaths[pm.manifestPath]; ok {
+				continue
+			}
+			seenManifestPaths[pm.manifestPath] = struct{}{}
+			u := pm.paved.UnstructuredContent()
+			forProvider := u["spec"].(map[string]interface{})["forProvider"].(map[string]interface{})
+			delete(forProvider, "depends_on")
+			if eg.crdFor != nil {
+				if err := eg.validateAgainstCRD(pm, forProvider); err != nil {
+					return errors.Wrapf(err, "cannot validate example manifest for resource: %s", n)
+				}
+			}
+			buff, err := yaml.Marshal(u)
+			if err != nil {
+				return errors.Wrapf(err, "cannot marshal example manifest for resource: %s", n)
+			}
+			manifestDir := filepath.Dir(pm.manifestPath)
+			if err := os.MkdirAll(manifestDir, 0750); err != nil {
+				return errors.Wrapf(err, "cannot mkdir %s", manifestDir)
+			}
+			// no sensitive info in the example manifest
+			if err := ioutil.WriteFile(pm.manifestPath, buff, 0644); err != nil { // nolint:gosec
+				return errors.Wrapf(err, "cannot write example manifest file %s for resource %s", pm.manifestPath, n)
+			}
 		}
 	}
 	return nil
 }
 
+// validateAgainstCRD checks forProvider against the schema of the CRD
+// generated for pm's own group/version/kind. On a validation failure it
+// either strips the offending fields (when eg.validateConfig says to) or
+// fails with the precise field paths the schema rejected.
+func (eg *ExampleGenerator) validateAgainstCRD(pm *pavedWithManifest, forProvider map[string]interface{}) error {
+	crd, err := eg.crdFor(pm.group, pm.version, pm.kind)
+	if err != nil {
+		return errors.Wrapf(err, "cannot load CRD for %s/%s, kind %s", pm.group, pm.version, pm.kind)
+	}
+	result, err := examplevalidate.Validate(crd, pm.version, forProvider, eg.validateConfig)
+	if err != nil {
+		return errors.Wrap(err, "cannot validate example manifest against its CRD schema")
+	}
+	for _, f := range result.StrippedFields {
+		fmt.Fprintf(os.Stderr, "warning: stripped field %q from example manifest %s, it does not validate against the CRD schema\n", f, pm.manifestPath)
+	}
+	if len(result.Errors) > 0 {
+		return errors.Errorf("example manifest does not validate against its CRD schema: %s", result.Errors.ToAggregate())
+	}
+	return nil
+}
+
 func (eg *ExampleGenerator) resolveReferencesOfPaved(pm *pavedWithManifest) error {
 	if pm.refsResolved {
 		return nil
 	}
 	pm.refsResolved = true
-	return errors.Wrap(eg.resolveReferences(pm.paved.UnstructuredContent()), "failed to resolve references of paved")
+	return errors.Wrap(eg.resolveReferences(pm.exampleID, pm.paved.UnstructuredContent()), "failed to resolve references of paved")
 }
 
-func (eg *ExampleGenerator) resolveReferences(params map[string]interface{}) error { // nolint:gocyclo
+func (eg *ExampleGenerator) resolveReferences(exampleID string, params map[string]interface{}) error { // nolint:gocyclo
 	for k, v := range params {
 		switch t := v.(type) {
 		case map[string]interface{}:
-			if err := eg.resolveReferences(t); err != nil {
+			if err := eg.resolveReferences(exampleID, t); err != nil {
 				return err
 			}
 
@@ -96,7 +188,7 @@ func (eg *ExampleGenerator) resolveReferences(params map[string]interface{}) err
 				if !ok {
 					continue
 				}
-				if err := eg.resolveReferences(eM); err != nil {
+				if err := eg.resolveReferences(exampleID, eM); err != nil {
 					return err
 				}
 			}
@@ -111,12 +203,19 @@ func (eg *ExampleGenerator) resolveReferences(params map[string]interface{}) err
 			if len(path) < 3 {
 				continue
 			}
-			pm := eg.resources[path[0]]
+			// resolve against the exact instance this reference names,
+			// "<resource type>.<resource name>", e.g. "aws_vpc.main", within
+			// the same documented example - never just "aws_vpc", and never
+			// a same-named sibling from a different example - so neither two
+			// differently-named instances of the same resource type nor two
+			// same-named instances from different examples are conflated.
+			refKey := fmt.Sprintf("%s.%s", path[0], path[1])
+			pm := eg.resources[exampleID][refKey]
 			if pm == nil || pm.paved == nil {
 				continue
 			}
 			if err := eg.resolveReferencesOfPaved(pm); err != nil {
-				return errors.Wrapf(err, "cannot recursively resolve references for %q", path[0])
+				return errors.Wrapf(err, "cannot recursively resolve references for %q", refKey)
 			}
 			pathStr := strings.Join(append([]string{"spec", "forProvider"}, path[2:]...), ".")
 			s, err := pm.paved.GetString(pathStr)
@@ -132,37 +231,155 @@ func (eg *ExampleGenerator) resolveReferences(params map[string]interface{}) err
 	return nil
 }
 
-// Generate generates an example manifest for the specified Terraform resource.
+// Generate generates example manifests for the specified Terraform resource,
+// one per documented example, subject to the resource's Examples config.
 func (eg *ExampleGenerator) Generate(group, version string, r *config.Resource, fieldTransformations map[string]tjtypes.Transformation) error {
 	rm := eg.configResource[r.Name].MetaResource
 	if rm == nil || len(rm.Examples) == 0 {
 		return nil
 	}
-	exampleParams := rm.Examples[0].Paved.UnstructuredContent()
-	transformFields(exampleParams, r.ExternalName.OmittedFields, fieldTransformations, "")
+	examples := rm.Examples
+	// r.Examples (config.ResourceExamples: Count, PreferredIndex) caps or
+	// pins which documented examples get emitted, for docs consumption.
+	// PreferredIndex is 0-based and nil when unset - a plain int could not
+	// tell "pin example 0" apart from "no preference".
+	if r.Examples.PreferredIndex != nil {
+		idx := *r.Examples.PreferredIndex
+		if idx < 0 || idx >= len(examples) {
+			return errors.Errorf("preferred example index %d (0-based) is out of range for resource %s with %d examples", idx, r.Name, len(examples))
+		}
+		examples = examples[idx : idx+1]
+	} else if r.Examples.Count > 0 && r.Examples.Count < len(examples) {
+		examples = examples[:r.Examples.Count]
+	}
+
+	groupDir := filepath.Join(eg.rootDir, "examples-generated", strings.ToLower(strings.Split(group, ".")[0]))
+	scope := &exampleScope{examples: make([]*pavedWithManifest, len(examples))}
+	for i, ex := range examples {
+		id := exampleID(r.Name, i)
+		exampleParams, hclScope, err := eg.resolveExampleScope(r, ex, i)
+		if err != nil {
+			return errors.Wrapf(err, "cannot resolve example %d for resource %s", i, r.Name)
+		}
+		transformFields(exampleParams, r.ExternalName.OmittedFields, fieldTransformations, "")
 
-	metadata := map[string]interface{}{
-		"name": "example",
+		metadata := map[string]interface{}{
+			"name": "example",
+		}
+		if len(rm.ExternalName) != 0 {
+			metadata["annotations"] = map[string]string{
+				xpmeta.AnnotationKeyExternalName: rm.ExternalName,
+			}
+		}
+		example := map[string]interface{}{
+			"apiVersion": fmt.Sprintf("%s/%s", group, version),
+			"kind":       r.Kind,
+			"metadata":   metadata,
+			"spec": map[string]interface{}{
+				"forProvider": exampleParams,
+			},
+		}
+		// a resource emitting a single example (after the Count/PreferredIndex
+		// selection above) keeps the historical flat "<kind>.yaml" path; with
+		// more than one, every example goes under a "<kind>/example-N.yaml"
+		// directory so they don't collide.
+		manifestPath := filepath.Join(groupDir, fmt.Sprintf("%s.yaml", strings.ToLower(r.Kind)))
+		if len(examples) > 1 {
+			manifestPath = filepath.Join(groupDir, strings.ToLower(r.Kind), fmt.Sprintf("example-%d.yaml", i+1))
+		}
+		scope.examples[i] = &pavedWithManifest{
+			manifestPath: manifestPath,
+			paved:        fieldpath.Pave(example),
+			exampleID:    id,
+			group:        group,
+			version:      version,
+			kind:         r.Kind,
+		}
+
+		// reuse the exact same resolved topology for the Composition/XRD
+		// scaffolding: only snippets that actually declare more than one
+		// resource produce one.
+		if eg.compositionGen != nil && hclScope != nil && len(hclScope.resources) > 1 {
+			name := fmt.Sprintf("%s-example-%d", strings.ToLower(r.Kind), i+1)
+			if err := eg.compositionGen.Generate(group, name, hclScope); err != nil {
+				return errors.Wrapf(err, "cannot generate composition example %d for resource %s", i, r.Name)
+			}
+		}
 	}
-	if len(rm.ExternalName) != 0 {
-		metadata["annotations"] = map[string]string{
-			xpmeta.AnnotationKeyExternalName: rm.ExternalName,
+	eg.primary[r.Name] = scope
+	return nil
+}
+
+// exampleID returns the identifier that scopes a single documented example of
+// resourceName - the index-th one - for eg.resources. Every resource instance
+// parsed from that example's HCL snippet is registered under this same ID,
+// so two examples that happen to reuse the same HCL resource name (e.g. both
+// declaring "resource \"aws_vpc\" \"main\"") never resolve a reference
+// against each other's data.
+func exampleID(resourceName string, index int) string {
+	return fmt.Sprintf("%s-example-%d", resourceName, index+1)
+}
+
+// resolveExampleScope turns one documented example into the forProvider
+// parameters that get written out for r, registering every resource
+// instance the example declares - r's own, and any siblings sharing its HCL
+// snippet - into eg.resources, scoped under this example's id and keyed by
+// "<resource type>.<resource name>" within that scope, so resolveReferences
+// can later resolve a reference against exactly the instance it names.
+// When the example carries raw HCL (ex.Manifest), that is parsed via
+// parseHCLExample and the resulting scope is also returned for
+// eg.compositionGen to use; examples that only carry the legacy
+// JSON-derived ex.Paved have no sibling information, so they're registered
+// under a synthetic "main" instance name and no scope is returned.
+func (eg *ExampleGenerator) resolveExampleScope(r *config.Resource, ex tjtypes.Example, index int) (map[string]interface{}, *hclExampleScope, error) {
+	id := exampleID(r.Name, index)
+	if ex.Manifest != "" {
+		scope, err := parseHCLExample([]byte(ex.Manifest), id+".tf")
+		if err != nil {
+			return nil, nil, err
+		}
+		name := ex.Name
+		if name == "" {
+			name = "main"
+		}
+		ownKey := fmt.Sprintf("%s.%s", r.Name, name)
+		own, ok := scope.resources[ownKey]
+		if !ok {
+			return nil, nil, errors.Errorf("HCL example does not declare the expected %q resource block", ownKey)
 		}
+		for key, m := range scope.resources {
+			eg.registerResourceInstance(id, key, m)
+		}
+		return own, scope, nil
 	}
-	example := map[string]interface{}{
-		"apiVersion": fmt.Sprintf("%s/%s", group, version),
-		"kind":       r.Kind,
-		"metadata":   metadata,
-		"spec": map[string]interface{}{
-			"forProvider": exampleParams,
-		},
+	if ex.Paved != nil {
+		m := ex.Paved.UnstructuredContent()
+		eg.registerResourceInstance(id, fmt.Sprintf("%s.main", r.Name), m)
+		return m, nil, nil
 	}
-	manifestDir := filepath.Join(eg.rootDir, "examples-generated", strings.ToLower(strings.Split(group, ".")[0]))
-	eg.resources[r.Name] = &pavedWithManifest{
-		manifestPath: filepath.Join(manifestDir, fmt.Sprintf("%s.yaml", strings.ToLower(r.Kind))),
-		paved:        fieldpath.Pave(example),
+	return nil, nil, errors.Errorf("example has neither a Manifest nor a Paved representation")
+}
+
+// registerResourceInstance indexes a resource instance's forProvider
+// parameters for reference resolution, scoped to exampleID, unless something
+// is already registered for that key within that scope.
+func (eg *ExampleGenerator) registerResourceInstance(exampleID, key string, forProvider map[string]interface{}) {
+	scope, ok := eg.resources[exampleID]
+	if !ok {
+		scope = make(map[string]*pavedWithManifest)
+		eg.resources[exampleID] = scope
+	}
+	if _, ok := scope[key]; ok {
+		return
+	}
+	scope[key] = &pavedWithManifest{
+		exampleID: exampleID,
+		paved: fieldpath.Pave(map[string]interface{}{
+			"spec": map[string]interface{}{
+				"forProvider": forProvider,
+			},
+		}),
 	}
-	return nil
 }
 
 func getHierarchicalName(prefix, name string) string {
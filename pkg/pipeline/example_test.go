@@ -0,0 +1,86 @@
+/*
+Copyright 2022 Upbound Inc.
+*/
+
+package pipeline
+
+import (
+	"testing"
+)
+
+func TestRegisterResourceInstanceIsScopedPerExample(t *testing.T) {
+	eg := NewExampleGenerator(t.TempDir(), nil)
+
+	eg.registerResourceInstance("aws_vpc-example-1", "aws_vpc.main", map[string]interface{}{
+		"cidr_block": "10.0.0.0/16",
+	})
+	eg.registerResourceInstance("aws_vpc-example-2", "aws_vpc.main", map[string]interface{}{
+		"cidr_block": "10.1.0.0/16",
+	})
+
+	first := eg.resources["aws_vpc-example-1"]["aws_vpc.main"]
+	second := eg.resources["aws_vpc-example-2"]["aws_vpc.main"]
+	if first == nil || second == nil {
+		t.Fatalf("expected both examples to register their own aws_vpc.main, got: %#v", eg.resources)
+	}
+	if first == second {
+		t.Fatalf("expected the two examples' aws_vpc.main instances to be distinct, got the same *pavedWithManifest")
+	}
+
+	got, err := first.paved.GetString("spec.forProvider.cidr_block")
+	if err != nil || got != "10.0.0.0/16" {
+		t.Errorf("example 1 cidr_block = %q, %v; want 10.0.0.0/16", got, err)
+	}
+	got, err = second.paved.GetString("spec.forProvider.cidr_block")
+	if err != nil || got != "10.1.0.0/16" {
+		t.Errorf("example 2 cidr_block = %q, %v; want 10.1.0.0/16", got, err)
+	}
+}
+
+// TestResolveReferencesDoesNotConflateSameNamedSiblingsAcrossExamples is a
+// regression test for a bug where eg.resources was keyed globally by
+// "<type>.<name>": the second of two documented examples that both declare a
+// sibling named e.g. "aws_vpc.main" would resolve its "${aws_vpc.main.id}"
+// reference against the first example's sibling instead of its own.
+func TestResolveReferencesDoesNotConflateSameNamedSiblingsAcrossExamples(t *testing.T) {
+	eg := NewExampleGenerator(t.TempDir(), nil)
+
+	eg.registerResourceInstance("aws_subnet-example-1", "aws_vpc.main", map[string]interface{}{
+		"id": "vpc-111",
+	})
+	eg.registerResourceInstance("aws_subnet-example-2", "aws_vpc.main", map[string]interface{}{
+		"id": "vpc-222",
+	})
+
+	for exID, want := range map[string]string{
+		"aws_subnet-example-1": "vpc-111",
+		"aws_subnet-example-2": "vpc-222",
+	} {
+		params := map[string]interface{}{
+			"vpc_id": "${aws_vpc.main.id}",
+		}
+		if err := eg.resolveReferences(exID, params); err != nil {
+			t.Fatalf("resolveReferences(%s) returned an error: %v", exID, err)
+		}
+		if got := params["vpc_id"]; got != want {
+			t.Errorf("resolveReferences(%s): vpc_id = %q, want %q", exID, got, want)
+		}
+	}
+}
+
+func TestResolveReferencesIgnoresUnknownExample(t *testing.T) {
+	eg := NewExampleGenerator(t.TempDir(), nil)
+	eg.registerResourceInstance("aws_subnet-example-1", "aws_vpc.main", map[string]interface{}{
+		"id": "vpc-111",
+	})
+
+	params := map[string]interface{}{
+		"vpc_id": "${aws_vpc.main.id}",
+	}
+	if err := eg.resolveReferences("aws_subnet-example-404", params); err != nil {
+		t.Fatalf("resolveReferences returned an error: %v", err)
+	}
+	if got, want := params["vpc_id"], "${aws_vpc.main.id}"; got != want {
+		t.Errorf("vpc_id = %q, want the reference left unresolved (%q)", got, want)
+	}
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2022 Upbound Inc.
+*/
+
+// Package examplevalidate validates generated example manifests against the
+// OpenAPI schema of the CRD generated for the same group/version/kind, so
+// the CRD and example generators can never silently drift apart.
+package examplevalidate
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiservervalidation "k8s.io/apiextensions-apiserver/pkg/apiserver/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Config controls how Validate reacts to an example manifest the CRD schema
+// rejects.
+type Config struct {
+	// StripInvalidFields removes the rejected fields from forProvider
+	// instead of failing the pipeline. The caller is responsible for
+	// surfacing Result.StrippedFields, e.g. by logging it.
+	StripInvalidFields bool
+}
+
+// Result is what Validate found wrong with an example manifest, if anything.
+type Result struct {
+	// Errors are the field-level validation failures the CRD schema
+	// reported for spec.forProvider. Empty when the manifest is valid, or
+	// when Config.StripInvalidFields was set and the offending fields were
+	// removed instead.
+	Errors field.ErrorList
+	// StrippedFields lists the dotted field paths removed from forProvider.
+	// Only populated when Config.StripInvalidFields is set.
+	StrippedFields []string
+}
+
+// Validate checks forProvider against the structural OpenAPI schema the CRD
+// declares for the given version. With cfg.StripInvalidFields unset, any
+// rejected field is reported in Result.Errors and the manifest is left
+// untouched. With it set, rejected fields are deleted from forProvider in
+// place and reported in Result.StrippedFields instead of failing validation;
+// any rejected field that could not actually be located and removed still
+// ends up in Result.Errors, so a field Validate couldn't strip is never
+// mistaken for one it did.
+func Validate(crd *apiextensionsv1.CustomResourceDefinition, version string, forProvider map[string]interface{}, cfg Config) (*Result, error) {
+	s, err := forProviderSchema(crd, version)
+	if err != nil {
+		return nil, err
+	}
+	internal := &apiextensions.JSONSchemaProps{}
+	if err := apiextensionsv1.Convert_v1_JSONSchemaProps_To_apiextensions_JSONSchemaProps(s, internal, nil); err != nil {
+		return nil, errors.Wrapf(err, "cannot convert CRD schema of %s to the internal apiextensions representation", crd.Name)
+	}
+	validator, _, err := apiservervalidation.NewSchemaValidator(internal)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot build a schema validator from the CRD schema of %s", crd.Name)
+	}
+
+	fldPath := field.NewPath("spec", "forProvider")
+	errs := apiservervalidation.ValidateCustomResource(fldPath, forProvider, validator)
+	if len(errs) == 0 {
+		return &Result{}, nil
+	}
+	if !cfg.StripInvalidFields {
+		return &Result{Errors: errs}, nil
+	}
+
+	stripped := make([]string, 0, len(errs))
+	var unresolved field.ErrorList
+	for _, e := range errs {
+		if removeFieldPath(forProvider, fldPath.String(), e.Field) {
+			stripped = append(stripped, e.Field)
+			continue
+		}
+		// we couldn't locate the field this error names, e.g. because its
+		// path addresses a list element we don't know how to walk yet -
+		// report it instead of silently calling the manifest clean.
+		unresolved = append(unresolved, e)
+	}
+	return &Result{Errors: unresolved, StrippedFields: stripped}, nil
+}
+
+func forProviderSchema(crd *apiextensionsv1.CustomResourceDefinition, version string) (*apiextensionsv1.JSONSchemaProps, error) {
+	for _, v := range crd.Spec.Versions {
+		if v.Name != version {
+			continue
+		}
+		if v.Schema == nil || v.Schema.OpenAPIV3Schema == nil {
+			return nil, errors.Errorf("CRD %s has no OpenAPI schema for version %s", crd.Name, version)
+		}
+		specSchema, ok := v.Schema.OpenAPIV3Schema.Properties["spec"]
+		if !ok {
+			return nil, errors.Errorf("CRD %s version %s schema has no spec", crd.Name, version)
+		}
+		forProvider, ok := specSchema.Properties["forProvider"]
+		if !ok {
+			return nil, errors.Errorf("CRD %s version %s schema has no spec.forProvider", crd.Name, version)
+		}
+		return &forProvider, nil
+	}
+	return nil, errors.Errorf("CRD %s declares no schema for version %s", crd.Name, version)
+}
+
+// pathSegment is one "."-delimited step of a field.Error's Field path, e.g.
+// "ingress" or, for a list element, "ingress[0]".
+type pathSegment struct {
+	name string
+	// hasIndex is true when name addresses an element of a list rather than
+	// a map key, e.g. "ingress[0]".
+	hasIndex bool
+	index    int
+}
+
+// parseFieldPath splits a field.Error's dotted path, such as
+// "spec.forProvider.ingress[0].fromPort", into its segments, recognizing the
+// "name[index]" form k8s uses to address list elements - these are NOT dot
+// separated from the list's own name, unlike every other nesting level.
+func parseFieldPath(path string) ([]pathSegment, error) {
+	raw := strings.Split(path, ".")
+	segments := make([]pathSegment, 0, len(raw))
+	for _, r := range raw {
+		open := strings.IndexByte(r, '[')
+		if open < 0 {
+			segments = append(segments, pathSegment{name: r})
+			continue
+		}
+		if !strings.HasSuffix(r, "]") {
+			return nil, errors.Errorf("cannot parse field path segment %q: unterminated [", r)
+		}
+		idx, err := strconv.Atoi(r[open+1 : len(r)-1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot parse index in field path segment %q", r)
+		}
+		segments = append(segments, pathSegment{name: r[:open], hasIndex: true, index: idx})
+	}
+	return segments, nil
+}
+
+// removeFieldPath deletes the leaf addressed by a field.Error's path (e.g.
+// "spec.forProvider.ingress[0].fromPort") from forProvider, returning
+// whether anything was actually removed. Returns false, rather than
+// deleting the wrong thing, whenever the path doesn't resolve cleanly -
+// e.g. an index out of range, or a segment that isn't the map/list the path
+// expects.
+func removeFieldPath(forProvider map[string]interface{}, rootPath, fieldPath string) bool {
+	prefix := rootPath + "."
+	if !strings.HasPrefix(fieldPath, prefix) {
+		return false
+	}
+	segments, err := parseFieldPath(strings.TrimPrefix(fieldPath, prefix))
+	if err != nil || len(segments) == 0 {
+		return false
+	}
+
+	var cur interface{} = forProvider
+	for _, seg := range segments[:len(segments)-1] {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		next, ok := m[seg.name]
+		if !ok {
+			return false
+		}
+		if seg.hasIndex {
+			list, ok := next.([]interface{})
+			if !ok || seg.index < 0 || seg.index >= len(list) {
+				return false
+			}
+			next = list[seg.index]
+		}
+		cur = next
+	}
+
+	m, ok := cur.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	leaf := segments[len(segments)-1]
+	if !leaf.hasIndex {
+		if _, ok := m[leaf.name]; !ok {
+			return false
+		}
+		delete(m, leaf.name)
+		return true
+	}
+	list, ok := m[leaf.name].([]interface{})
+	if !ok || leaf.index < 0 || leaf.index >= len(list) {
+		return false
+	}
+	m[leaf.name] = append(list[:leaf.index], list[leaf.index+1:]...)
+	return true
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2022 Upbound Inc.
+*/
+
+package examplevalidate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRemoveFieldPathMapLeaf(t *testing.T) {
+	forProvider := map[string]interface{}{
+		"tags": map[string]interface{}{
+			"foo": "bar",
+		},
+	}
+	if ok := removeFieldPath(forProvider, "spec.forProvider", "spec.forProvider.tags.foo"); !ok {
+		t.Fatal("removeFieldPath returned false, want true")
+	}
+	want := map[string]interface{}{"tags": map[string]interface{}{}}
+	if !reflect.DeepEqual(forProvider, want) {
+		t.Errorf("forProvider = %#v, want %#v", forProvider, want)
+	}
+}
+
+func TestRemoveFieldPathListElementLeaf(t *testing.T) {
+	forProvider := map[string]interface{}{
+		"ingress": []interface{}{
+			map[string]interface{}{"from_port": float64(80)},
+			map[string]interface{}{"from_port": float64(443)},
+		},
+	}
+	if ok := removeFieldPath(forProvider, "spec.forProvider", "spec.forProvider.ingress[1]"); !ok {
+		t.Fatal("removeFieldPath returned false, want true")
+	}
+	want := map[string]interface{}{
+		"ingress": []interface{}{
+			map[string]interface{}{"from_port": float64(80)},
+		},
+	}
+	if !reflect.DeepEqual(forProvider, want) {
+		t.Errorf("forProvider = %#v, want %#v", forProvider, want)
+	}
+}
+
+func TestRemoveFieldPathFieldOfListElement(t *testing.T) {
+	forProvider := map[string]interface{}{
+		"ingress": []interface{}{
+			map[string]interface{}{"from_port": float64(80), "to_port": float64(80)},
+			map[string]interface{}{"from_port": float64(443), "to_port": float64(443)},
+		},
+	}
+	if ok := removeFieldPath(forProvider, "spec.forProvider", "spec.forProvider.ingress[0].from_port"); !ok {
+		t.Fatal("removeFieldPath returned false, want true")
+	}
+	want := map[string]interface{}{
+		"ingress": []interface{}{
+			map[string]interface{}{"to_port": float64(80)},
+			map[string]interface{}{"from_port": float64(443), "to_port": float64(443)},
+		},
+	}
+	if !reflect.DeepEqual(forProvider, want) {
+		t.Errorf("forProvider = %#v, want %#v", forProvider, want)
+	}
+}
+
+func TestRemoveFieldPathReturnsFalseWhenUnresolvable(t *testing.T) {
+	cases := map[string]struct {
+		forProvider map[string]interface{}
+		fieldPath   string
+	}{
+		"WrongRoot": {
+			forProvider: map[string]interface{}{"tags": map[string]interface{}{"foo": "bar"}},
+			fieldPath:   "status.atProvider.tags.foo",
+		},
+		"MissingKey": {
+			forProvider: map[string]interface{}{"tags": map[string]interface{}{"foo": "bar"}},
+			fieldPath:   "spec.forProvider.tags.baz",
+		},
+		"IndexOutOfRange": {
+			forProvider: map[string]interface{}{"ingress": []interface{}{map[string]interface{}{"from_port": float64(80)}}},
+			fieldPath:   "spec.forProvider.ingress[5].from_port",
+		},
+		"NotAList": {
+			forProvider: map[string]interface{}{"ingress": map[string]interface{}{"from_port": float64(80)}},
+			fieldPath:   "spec.forProvider.ingress[0].from_port",
+		},
+		"MalformedIndex": {
+			forProvider: map[string]interface{}{"ingress": []interface{}{map[string]interface{}{"from_port": float64(80)}}},
+			fieldPath:   "spec.forProvider.ingress[abc].from_port",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if ok := removeFieldPath(tc.forProvider, "spec.forProvider", tc.fieldPath); ok {
+				t.Errorf("removeFieldPath returned true, want false")
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2022 Upbound Inc.
+*/
+
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/pkg/errors"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// PavedExampleFromHCL parses a raw Terraform HCL example, as scraped from an
+// upstream provider's website/docs/r/*.markdown, and returns the paved
+// attributes of the named resource instance (identified by its HCL
+// "resource_type" and "resource_name" labels). The returned Paved is
+// interchangeable with the JSON-derived ones upstream metadata already
+// provides today, so a MetaResource's Example.Paved can be populated from
+// either source.
+//
+// This is a single-resource convenience wrapper: sibling resources declared
+// in the same snippet are parsed too, but their attributes are discarded, and
+// references to them in the named resource stay unresolved "${...}" strings.
+// Callers that need the full multi-resource scope - ExampleGenerator's
+// reference resolution and CompositionExampleGenerator both do - must call
+// parseHCLExample directly instead, so the shared resources map survives.
+func PavedExampleFromHCL(src []byte, filename, resourceType, resourceName string) (*fieldpath.Paved, error) {
+	scope, err := parseHCLExample(src, filename)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := scope.resources[fmt.Sprintf("%s.%s", resourceType, resourceName)]
+	if !ok {
+		return nil, errors.Errorf("no %q resource named %q found in HCL example %s", resourceType, resourceName, filename)
+	}
+	return fieldpath.Pave(m), nil
+}
+
+// hclExampleScope is the set of Terraform resource blocks declared together
+// in a single upstream documentation snippet, keyed by "<resource
+// type>.<resource name>" exactly as Terraform's own interpolation syntax
+// addresses them, e.g. "aws_vpc.main". All resources parsed from the same
+// snippet share this one scope, matching how resolveReferences already
+// expects to find a sibling resource's attributes.
+type hclExampleScope struct {
+	resources map[string]map[string]interface{}
+}
+
+// parseHCLExample parses a Terraform documentation example, i.e. one or more
+// "resource" blocks as found in website/docs/r/*.markdown of Terraform
+// providers, into a scope of plain map[string]interface{} manifests ready to
+// be paved. Interpolation expressions such as aws_vpc.main.id are preserved
+// verbatim as "${aws_vpc.main.id}" strings, and file("path") calls the same
+// way, so the existing reRef/reFile matching in resolveReferences and
+// getSecretRef continues to work unmodified.
+func parseHCLExample(src []byte, filename string) (*hclExampleScope, error) {
+	f, diags := hclparse.NewParser().ParseHCL(src, filename)
+	if diags.HasErrors() {
+		return nil, errors.Wrapf(diags, "cannot parse HCL example %s", filename)
+	}
+	body, ok := f.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, errors.Errorf("unexpected HCL body implementation for %s", filename)
+	}
+
+	scope := &hclExampleScope{resources: make(map[string]map[string]interface{})}
+	for _, block := range body.Blocks {
+		if block.Type != "resource" || len(block.Labels) != 2 {
+			continue
+		}
+		resourceType, resourceName := block.Labels[0], block.Labels[1]
+		m, err := hclBodyToMap(block.Body, src)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot convert HCL body of %s.%s", resourceType, resourceName)
+		}
+		scope.resources[fmt.Sprintf("%s.%s", resourceType, resourceName)] = m
+	}
+	return scope, nil
+}
+
+func hclBodyToMap(body *hclsyntax.Body, src []byte) (map[string]interface{}, error) {
+	m := make(map[string]interface{}, len(body.Attributes)+len(body.Blocks))
+	for name, attr := range body.Attributes {
+		v, err := hclExpressionToValue(attr.Expr, src)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot convert attribute %q", name)
+		}
+		m[name] = v
+	}
+	for _, block := range body.Blocks {
+		bm, err := hclBodyToMap(block.Body, src)
+		if err != nil {
+			return nil, err
+		}
+		// repeated nested blocks, e.g. several "ingress" blocks, accumulate
+		// into a list, matching how Terraform itself treats them.
+		existing, ok := m[block.Type]
+		switch e := existing.(type) {
+		case nil:
+			if ok {
+				m[block.Type] = []interface{}{bm}
+			} else {
+				m[block.Type] = bm
+			}
+		case []interface{}:
+			m[block.Type] = append(e, bm)
+		default:
+			m[block.Type] = []interface{}{e, bm}
+		}
+	}
+	return m, nil
+}
+
+// hclExpressionToValue evaluates an HCL expression into a plain Go value.
+// Expressions that can be statically evaluated (literals, lists, objects with
+// no variables) are converted directly. Anything that cannot - a reference
+// to a sibling resource's attribute, a file() call, or any other function
+// call - is preserved verbatim as its original source text wrapped in
+// "${...}", the same interpolation format the rest of the pipeline already
+// understands.
+func hclExpressionToValue(expr hclsyntax.Expression, src []byte) (interface{}, error) {
+	val, diags := expr.Value(nil)
+	if !diags.HasErrors() && val.IsWhollyKnown() {
+		return ctyValueToInterface(val)
+	}
+	return fmt.Sprintf("${%s}", strings.TrimSpace(string(expr.Range().SliceBytes(src)))), nil
+}
+
+func ctyValueToInterface(v cty.Value) (interface{}, error) {
+	buff, err := ctyjson.Marshal(v, v.Type())
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot marshal cty value to JSON")
+	}
+	var out interface{}
+	if err := json.Unmarshal(buff, &out); err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal cty JSON representation")
+	}
+	return out, nil
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2022 Upbound Inc.
+*/
+
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHCLExampleLiteralsAndRepeatedBlocks(t *testing.T) {
+	src := []byte(`
+resource "aws_vpc" "main" {
+  cidr_block = "10.0.0.0/16"
+  enable_dns_support = true
+
+  tags = {
+    Name = "main"
+  }
+
+  ingress {
+    from_port = 80
+    to_port   = 80
+  }
+
+  ingress {
+    from_port = 443
+    to_port   = 443
+  }
+}
+`)
+	scope, err := parseHCLExample(src, "aws_vpc-example-1.tf")
+	if err != nil {
+		t.Fatalf("parseHCLExample returned an error: %v", err)
+	}
+	m, ok := scope.resources["aws_vpc.main"]
+	if !ok {
+		t.Fatalf("expected scope.resources to contain %q, got: %v", "aws_vpc.main", scope.resources)
+	}
+
+	want := map[string]interface{}{
+		"cidr_block":         "10.0.0.0/16",
+		"enable_dns_support": true,
+		"tags":               map[string]interface{}{"Name": "main"},
+		"ingress": []interface{}{
+			map[string]interface{}{"from_port": float64(80), "to_port": float64(80)},
+			map[string]interface{}{"from_port": float64(443), "to_port": float64(443)},
+		},
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("parseHCLExample: -want, +got:\nwant: %#v\ngot:  %#v", want, m)
+	}
+}
+
+func TestParseHCLExampleInterpolationAndFileCall(t *testing.T) {
+	src := []byte(`
+resource "aws_vpc" "main" {
+  cidr_block = "10.0.0.0/16"
+}
+
+resource "aws_subnet" "primary" {
+  vpc_id   = aws_vpc.main.id
+  key_file = file("./id_rsa.pub")
+}
+`)
+	scope, err := parseHCLExample(src, "aws_subnet-example-1.tf")
+	if err != nil {
+		t.Fatalf("parseHCLExample returned an error: %v", err)
+	}
+
+	subnet, ok := scope.resources["aws_subnet.primary"]
+	if !ok {
+		t.Fatalf("expected scope.resources to contain %q, got: %v", "aws_subnet.primary", scope.resources)
+	}
+	if got, want := subnet["vpc_id"], "${aws_vpc.main.id}"; got != want {
+		t.Errorf("vpc_id = %q, want %q", got, want)
+	}
+	if got, want := subnet["key_file"], `${file("./id_rsa.pub")}`; got != want {
+		t.Errorf("key_file = %q, want %q", got, want)
+	}
+
+	if _, ok := scope.resources["aws_vpc.main"]; !ok {
+		t.Errorf("expected sibling resource %q to also be present in the scope", "aws_vpc.main")
+	}
+}
+
+func TestPavedExampleFromHCLReturnsOnlyTheNamedResource(t *testing.T) {
+	src := []byte(`
+resource "aws_vpc" "main" {
+  cidr_block = "10.0.0.0/16"
+}
+
+resource "aws_subnet" "primary" {
+  vpc_id = aws_vpc.main.id
+}
+`)
+	p, err := PavedExampleFromHCL(src, "aws_subnet-example-1.tf", "aws_subnet", "primary")
+	if err != nil {
+		t.Fatalf("PavedExampleFromHCL returned an error: %v", err)
+	}
+	got, err := p.GetString("vpc_id")
+	if err != nil {
+		t.Fatalf("GetString(vpc_id) returned an error: %v", err)
+	}
+	if want := "${aws_vpc.main.id}"; got != want {
+		t.Errorf("vpc_id = %q, want %q", got, want)
+	}
+
+	if _, err := PavedExampleFromHCL(src, "aws_subnet-example-1.tf", "aws_subnet", "missing"); err == nil {
+		t.Error("expected an error for a resource name not present in the HCL example")
+	}
+}
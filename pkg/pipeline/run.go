@@ -6,15 +6,20 @@ package pipeline
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 
 	"github.com/crossplane/upjet/pkg/config"
-	"github.com/crossplane/upjet/pkg/examples"
+	"github.com/crossplane/upjet/pkg/pipeline/examplevalidate"
 )
 
 type terraformedInput struct {
@@ -22,6 +27,32 @@ type terraformedInput struct {
 	ParametersTypeName string
 }
 
+// groupVersionResult is what a single (group, version) worker hands back to
+// the serial reducer in Run. Workers never touch the shared
+// apiVersionPkgList/controllerPkgMap directly - they only return what they'd
+// like added to them - so the reducer can merge everything without a mutex
+// and in a deterministic, sorted order.
+type groupVersionResult struct {
+	group, version    string
+	apiVersionPkgs    []string
+	controllerPkgAdds map[string][]string
+	resourceCount     int
+	err               error
+}
+
+// pipelineConcurrency returns how many (group, version) tuples Run processes
+// at once. It defaults to GOMAXPROCS and can be overridden with the
+// UPJET_PIPELINE_CONCURRENCY env var, e.g. to dial it down on memory
+// constrained CI runners.
+func pipelineConcurrency() int {
+	if v := os.Getenv("UPJET_PIPELINE_CONCURRENCY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
 // Run runs the Upjet code generation pipelines.
 func Run(pc *config.Provider, rootDir string) { //nolint:gocyclo
 	// Note(turkenh): nolint reasoning - this is the main function of the code
@@ -46,10 +77,30 @@ func Run(pc *config.Provider, rootDir string) { //nolint:gocyclo
 		resourcesGroups[group][resource.Version][name] = resource
 	}
 
-	exampleGen := examples.NewGenerator(rootDir, pc.ModulePath, pc.ShortName, pc.Resources)
-	if err := exampleGen.SetReferenceTypes(pc.Resources); err != nil {
-		panic(errors.Wrap(err, "cannot set reference types for resources"))
-	}
+	exampleGen := NewExampleGenerator(rootDir, pc.Resources)
+	exampleGen.WithCompositions(NewCompositionExampleGenerator(rootDir, pc.Resources))
+	// crds collects the CRD generated for each group/version/kind so
+	// exampleGen can validate a resource's example manifests against the
+	// schema crdGen just produced for it, closing the loop between the two
+	// generators. Guarded separately from exampleGenMu since it's written to
+	// from runGroupVersion right after crdGen.Generate, before exampleGen is
+	// ever touched for that resource.
+	var crdMu sync.Mutex
+	crds := make(map[string]*apiextensionsv1.CustomResourceDefinition)
+	exampleGen.WithCRDValidation(func(group, version, kind string) (*apiextensionsv1.CustomResourceDefinition, error) {
+		crdMu.Lock()
+		defer crdMu.Unlock()
+		crd, ok := crds[crdRegistryKey(group, version, kind)]
+		if !ok {
+			return nil, errors.Errorf("no CRD was generated for %s/%s, kind %s", group, version, kind)
+		}
+		return crd, nil
+	}, examplevalidate.Config{})
+	// exampleGen is shared by every (group, version) worker below; guard
+	// calls into it so its internal resource map is never written to from
+	// two goroutines at once.
+	var exampleGenMu sync.Mutex
+
 	// Add ProviderConfig API package to the list of API version packages.
 	apiVersionPkgList := make([]string, 0)
 	for _, p := range pc.BasePackages.APIVersion {
@@ -87,71 +138,62 @@ func Run(pc *config.Provider, rootDir string) { //nolint:gocyclo
 			controllerPkgMap[config.PackageNameMonolith] = append(controllerPkgMap[config.PackageNameMonolith], path)
 		}
 	}
-	count := 0
+
+	type groupVersion struct {
+		group, version string
+		resources      map[string]*config.Resource
+	}
+	var tasks []groupVersion
 	for group, versions := range resourcesGroups {
 		for version, resources := range versions {
-			var tfResources []*terraformedInput
-			versionGen := NewVersionGenerator(rootDir, pc.ModulePath, group, version)
-			crdGen := NewCRDGenerator(versionGen.Package(), rootDir, pc.ShortName, group, version)
-			tfGen := NewTerraformedGenerator(versionGen.Package(), rootDir, group, version)
-			conversionHubGen := NewConversionHubGenerator(versionGen.Package(), rootDir, group, version)
-			conversionSpokeGen := NewConversionSpokeGenerator(versionGen.Package(), rootDir, group, version)
-			ctrlGen := NewControllerGenerator(rootDir, pc.ModulePath, group)
-
-			for _, name := range sortedResources(resources) {
-				paramTypeName, err := crdGen.Generate(resources[name])
-				if err != nil {
-					panic(errors.Wrapf(err, "cannot generate crd for resource %s", name))
-				}
-				tfResources = append(tfResources, &terraformedInput{
-					Resource:           resources[name],
-					ParametersTypeName: paramTypeName,
-				})
-
-				featuresPkgPath := ""
-				if pc.FeaturesPackage != "" {
-					featuresPkgPath = filepath.Join(pc.ModulePath, pc.FeaturesPackage)
-				}
-				ctrlPkgPath, err := ctrlGen.Generate(resources[name], versionGen.Package().Path(), featuresPkgPath)
-				if err != nil {
-					panic(errors.Wrapf(err, "cannot generate controller for resource %s", name))
-				}
-				sGroup := strings.Split(group, ".")[0]
-				controllerPkgMap[sGroup] = append(controllerPkgMap[sGroup], ctrlPkgPath)
-				controllerPkgMap[config.PackageNameMonolith] = append(controllerPkgMap[config.PackageNameMonolith], ctrlPkgPath)
-				if err := exampleGen.Generate(group, version, resources[name]); err != nil {
-					panic(errors.Wrapf(err, "cannot generate example manifest for resource %s", name))
-				}
-				count++
-			}
-
-			if err := tfGen.Generate(tfResources, version); err != nil {
-				panic(errors.Wrapf(err, "cannot generate terraformed for resource %s", group))
-			}
+			tasks = append(tasks, groupVersion{group: group, version: version, resources: resources})
+		}
+	}
 
-			if err := conversionHubGen.Generate(tfResources, version); err != nil {
-				panic(errors.Wrapf(err, "cannot generate the conversion.Hub function for the resource group %q", group))
-			}
+	sem := make(chan struct{}, pipelineConcurrency())
+	results := make(chan groupVersionResult, len(tasks))
+	var wg sync.WaitGroup
+	for _, t := range tasks {
+		t := t
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results <- runGroupVersion(pc, rootDir, exampleGen, &exampleGenMu, crds, &crdMu, t.group, t.version, t.resources)
+		}()
+	}
+	wg.Wait()
+	close(results)
 
-			if err := conversionSpokeGen.Generate(tfResources); err != nil {
-				panic(errors.Wrapf(err, "cannot generate the conversion.Convertible functions for the resource group %q", group))
-			}
+	ordered := make([]groupVersionResult, 0, len(tasks))
+	for r := range results {
+		ordered = append(ordered, r)
+	}
+	// sort the reduced results before merging them so that generated code,
+	// e.g. register.go and the provider setup file, stays byte-stable across
+	// runs regardless of which worker finished first.
+	sort.Slice(ordered, func(i, j int) bool {
+		if ordered[i].group != ordered[j].group {
+			return ordered[i].group < ordered[j].group
+		}
+		return ordered[i].version < ordered[j].version
+	})
 
-			if err := versionGen.Generate(); err != nil {
-				panic(errors.Wrap(err, "cannot generate version files"))
-			}
-			p := versionGen.Package().Path()
-			apiVersionPkgList = append(apiVersionPkgList, p)
-			for _, r := range resources {
-				// if there are spoke versions for the given group.Kind
-				if spokeVersions := conversionSpokeGen.SpokeVersionsMap[fmt.Sprintf("%s.%s", r.ShortGroup, r.Kind)]; spokeVersions != nil {
-					base := filepath.Dir(p)
-					for _, sv := range spokeVersions {
-						apiVersionPkgList = append(apiVersionPkgList, filepath.Join(base, sv))
-					}
-				}
-			}
+	count := 0
+	for _, r := range ordered {
+		if r.err != nil {
+			panic(r.err)
+		}
+		apiVersionPkgList = append(apiVersionPkgList, r.apiVersionPkgs...)
+		for g, ps := range r.controllerPkgAdds {
+			controllerPkgMap[g] = append(controllerPkgMap[g], ps...)
 		}
+		count += r.resourceCount
+	}
+	sort.Strings(apiVersionPkgList)
+	for g := range controllerPkgMap {
+		sort.Strings(controllerPkgMap[g])
 	}
 
 	if err := exampleGen.StoreExamples(); err != nil {
@@ -170,19 +212,125 @@ func Run(pc *config.Provider, rootDir string) { //nolint:gocyclo
 	// NOTE(muvaf): gosec linter requires that the whole command is hard-coded.
 	// So, we set the directory of the command instead of passing in the directory
 	// as an argument to "find".
-	apisCmd := exec.Command("bash", "-c", "goimports -w $(find . -iname 'zz_*')")
-	apisCmd.Dir = filepath.Clean(filepath.Join(rootDir, "apis"))
-	if out, err := apisCmd.CombinedOutput(); err != nil {
-		panic(errors.Wrap(err, "cannot run goimports for apis folder: "+string(out)))
+	var goimportsWG sync.WaitGroup
+	goimportsErrs := make(chan error, 2)
+	goimportsWG.Add(2)
+	go func() {
+		defer goimportsWG.Done()
+		apisCmd := exec.Command("bash", "-c", "goimports -w $(find . -iname 'zz_*')")
+		apisCmd.Dir = filepath.Clean(filepath.Join(rootDir, "apis"))
+		if out, err := apisCmd.CombinedOutput(); err != nil {
+			goimportsErrs <- errors.Wrap(err, "cannot run goimports for apis folder: "+string(out))
+		}
+	}()
+	go func() {
+		defer goimportsWG.Done()
+		internalCmd := exec.Command("bash", "-c", "goimports -w $(find . -iname 'zz_*')")
+		internalCmd.Dir = filepath.Clean(filepath.Join(rootDir, "internal"))
+		if out, err := internalCmd.CombinedOutput(); err != nil {
+			goimportsErrs <- errors.Wrap(err, "cannot run goimports for internal folder: "+string(out))
+		}
+	}()
+	goimportsWG.Wait()
+	close(goimportsErrs)
+	for err := range goimportsErrs {
+		panic(err)
+	}
+
+	fmt.Printf("\nGenerated %d resources!\n", count)
+}
+
+// runGroupVersion runs the code generation pipelines for a single
+// (group, version) tuple. It owns its own generators so it can run
+// concurrently with the other tuples Run dispatches. The API version and
+// controller packages it produces are reported back through its return value;
+// the shared crds map and exampleGen are written directly, each under its
+// own mutex.
+func runGroupVersion(pc *config.Provider, rootDir string, exampleGen *ExampleGenerator, exampleGenMu *sync.Mutex, crds map[string]*apiextensionsv1.CustomResourceDefinition, crdMu *sync.Mutex, group, version string, resources map[string]*config.Resource) groupVersionResult { //nolint:gocyclo
+	res := groupVersionResult{group: group, version: version, controllerPkgAdds: make(map[string][]string)}
+
+	var tfResources []*terraformedInput
+	versionGen := NewVersionGenerator(rootDir, pc.ModulePath, group, version)
+	crdGen := NewCRDGenerator(versionGen.Package(), rootDir, pc.ShortName, group, version)
+	tfGen := NewTerraformedGenerator(versionGen.Package(), rootDir, group, version)
+	conversionHubGen := NewConversionHubGenerator(versionGen.Package(), rootDir, group, version)
+	conversionSpokeGen := NewConversionSpokeGenerator(versionGen.Package(), rootDir, group, version)
+	ctrlGen := NewControllerGenerator(rootDir, pc.ModulePath, group)
+
+	for _, name := range sortedResources(resources) {
+		paramTypeName, fieldTransformations, crd, err := crdGen.Generate(resources[name])
+		if err != nil {
+			res.err = errors.Wrapf(err, "cannot generate crd for resource %s", name)
+			return res
+		}
+		crdMu.Lock()
+		crds[crdRegistryKey(group, version, resources[name].Kind)] = crd
+		crdMu.Unlock()
+		tfResources = append(tfResources, &terraformedInput{
+			Resource:           resources[name],
+			ParametersTypeName: paramTypeName,
+		})
+
+		featuresPkgPath := ""
+		if pc.FeaturesPackage != "" {
+			featuresPkgPath = filepath.Join(pc.ModulePath, pc.FeaturesPackage)
+		}
+		ctrlPkgPath, err := ctrlGen.Generate(resources[name], versionGen.Package().Path(), featuresPkgPath)
+		if err != nil {
+			res.err = errors.Wrapf(err, "cannot generate controller for resource %s", name)
+			return res
+		}
+		sGroup := strings.Split(group, ".")[0]
+		res.controllerPkgAdds[sGroup] = append(res.controllerPkgAdds[sGroup], ctrlPkgPath)
+		res.controllerPkgAdds[config.PackageNameMonolith] = append(res.controllerPkgAdds[config.PackageNameMonolith], ctrlPkgPath)
+
+		exampleGenMu.Lock()
+		err = exampleGen.Generate(group, version, resources[name], fieldTransformations)
+		exampleGenMu.Unlock()
+		if err != nil {
+			res.err = errors.Wrapf(err, "cannot generate example manifest for resource %s", name)
+			return res
+		}
+		res.resourceCount++
 	}
 
-	internalCmd := exec.Command("bash", "-c", "goimports -w $(find . -iname 'zz_*')")
-	internalCmd.Dir = filepath.Clean(filepath.Join(rootDir, "internal"))
-	if out, err := internalCmd.CombinedOutput(); err != nil {
-		panic(errors.Wrap(err, "cannot run goimports for internal folder: "+string(out)))
+	if err := tfGen.Generate(tfResources, version); err != nil {
+		res.err = errors.Wrapf(err, "cannot generate terraformed for resource %s", group)
+		return res
 	}
 
-	fmt.Printf("\nGenerated %d resources!\n", count)
+	if err := conversionHubGen.Generate(tfResources, version); err != nil {
+		res.err = errors.Wrapf(err, "cannot generate the conversion.Hub function for the resource group %q", group)
+		return res
+	}
+
+	if err := conversionSpokeGen.Generate(tfResources); err != nil {
+		res.err = errors.Wrapf(err, "cannot generate the conversion.Convertible functions for the resource group %q", group)
+		return res
+	}
+
+	if err := versionGen.Generate(); err != nil {
+		res.err = errors.Wrap(err, "cannot generate version files")
+		return res
+	}
+	p := versionGen.Package().Path()
+	res.apiVersionPkgs = append(res.apiVersionPkgs, p)
+	for _, r := range resources {
+		// if there are spoke versions for the given group.Kind
+		if spokeVersions := conversionSpokeGen.SpokeVersionsMap[fmt.Sprintf("%s.%s", r.ShortGroup, r.Kind)]; spokeVersions != nil {
+			base := filepath.Dir(p)
+			for _, sv := range spokeVersions {
+				res.apiVersionPkgs = append(res.apiVersionPkgs, filepath.Join(base, sv))
+			}
+		}
+	}
+	return res
+}
+
+// crdRegistryKey identifies a generated CRD by its group/version/kind, for
+// the map exampleGen's CRDGetter looks CRDs up in.
+func crdRegistryKey(group, version, kind string) string {
+	return fmt.Sprintf("%s/%s/%s", group, version, kind)
 }
 
 func sortedResources(m map[string]*config.Resource) []string {